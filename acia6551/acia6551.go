@@ -0,0 +1,262 @@
+/*
+	Package acia6551 emulates the MOS Technology 6551 Asynchronous
+	Communications Interface Adapter (ACIA), a UART commonly paired with a
+	6502 to give it a serial port.
+
+	The 2-bit RS (register select) is exposed as 4 bytes of address-space:
+		0x0: Data register; write: transmit, read: receive.
+		0x1: Status register (read-only); write resets the chip.
+		0x2: Command register.
+		0x3: Control register; low nibble selects the baud rate, bits 5-6
+		     select the word length, and bit 7 selects the stop bit count.
+
+	Status register bits:
+		0x04: Overrun, a byte arrived before the previous one was read.
+		0x08: RDRF, Receive Data Register Full (a byte is waiting to be read).
+		0x10: TDRE, Transmit Data Register Empty (ready to accept a byte).
+		0x80: IRQ, an enabled interrupt condition is pending.
+
+	Command register bit 0x02 (Receiver interrupt disable) gates whether
+	RDRF going high asserts IRQ; AttachIRQ connects that condition to the
+	CPU's shared IRQ line the same way via6522.Via6522.AttachIRQ does.
+
+	Tick(cycles) advances a baud-rate-derived clock (assuming a ~1MHz bus,
+	as on a C64) and polls the SerialBackend for newly-arrived bytes; actual
+	byte delivery timing (not just availability) is not modelled. A byte
+	that arrives while the previous one is still unread is dropped and sets
+	the Overrun status bit rather than silently replacing it.
+*/
+package acia6551
+
+import "fmt"
+
+const (
+	aciaData    = 0x0
+	aciaStatus  = 0x1
+	aciaCommand = 0x2
+	aciaControl = 0x3
+
+	statusOverrun = 0x04
+	statusRdrf    = 0x08
+	statusTdre    = 0x10
+	statusIrq     = 0x80
+
+	commandReceiverIrqDisable = 0x02
+
+	controlBaudMask     = 0x0F
+	controlWordLenMask  = 0x60
+	controlWordLenShift = 5
+	controlStopBits2    = 0x80
+)
+
+// controlWordLengths maps the control register's word-length bits (5-6) to
+// a data bit count, per the 6551 datasheet.
+var controlWordLengths = [4]int{8, 7, 6, 5}
+
+// baudRates maps the control register's low nibble to a baud rate, per
+// the 6551 datasheet (0 means "use an external clock", which we treat the
+// same as the slowest supported rate since there's no external clock here).
+var baudRates = [16]uint64{
+	50, 50, 75, 110, 135, 150, 300, 600,
+	1200, 1800, 2400, 3600, 4800, 7200, 9600, 19200,
+}
+
+// busHz is the assumed CPU clock, used to convert a baud rate into a
+// Tick() cycle count per byte (8N1 framing: 10 bits per byte).
+const busHz = 1000000
+
+// IrqLine is the shared, aggregated interrupt line exposed by a CPU (such as
+// go6502.Cpu's IRQ line, or a via6522.IrqAggregator source attached to it).
+type IrqLine interface {
+	Assert()
+	Release()
+}
+
+// SerialBackend connects the ACIA's data register to an actual byte stream,
+// such as a terminal (stdio) or a pseudo-terminal.
+type SerialBackend interface {
+	// Send transmits a byte.
+	Send(b byte) error
+
+	// Recv returns the next received byte, if one is available.
+	Recv() (b byte, ok bool)
+
+	// SetFraming notifies the backend of the word length and stop bit
+	// count selected by a control register write. Backends that are just
+	// a byte stream (stdio, a pty's master side) have no framing of their
+	// own to update and can treat this as a no-op.
+	SetFraming(dataBits, stopBits int)
+
+	// Close releases the backend's resources.
+	Close() error
+}
+
+// Acia6551 emulates a 6551 ACIA attached to a SerialBackend.
+type Acia6551 struct {
+	backend SerialBackend
+	base    uint16
+
+	command byte
+	control byte
+	rxData  byte
+	rxFull  bool
+	overrun bool
+
+	cyclesSinceTick uint64
+	cyclesPerByte   uint64
+
+	irq IrqLine
+}
+
+// NewAcia6551 creates an ACIA backed by backend, mapped at base (used only
+// to label the device in String()).
+func NewAcia6551(backend SerialBackend, base uint16) *Acia6551 {
+	a := &Acia6551{backend: backend, base: base}
+	a.setBaudRate(a.control)
+	return a
+}
+
+// AttachIRQ connects the ACIA's RDRF-driven interrupt output to the CPU's
+// shared IRQ line.
+func (a *Acia6551) AttachIRQ(irq IrqLine) {
+	a.irq = irq
+	a.updateIrq()
+}
+
+// Shutdown releases the backend.
+func (a *Acia6551) Shutdown() {
+	a.backend.Close()
+}
+
+func (a *Acia6551) Read(addr uint16) byte {
+	switch addr {
+	default:
+		panic(fmt.Sprintf("read from 0x%X not handled by Acia6551", addr))
+	case aciaData:
+		a.rxFull = false
+		a.updateIrq()
+		return a.rxData
+	case aciaStatus:
+		s := a.status()
+		a.overrun = false
+		return s
+	case aciaCommand:
+		return a.command
+	case aciaControl:
+		return a.control
+	}
+}
+
+func (a *Acia6551) Write(addr uint16, data byte) {
+	switch addr {
+	default:
+		panic(fmt.Sprintf("write to 0x%X not handled by Acia6551", addr))
+	case aciaData:
+		a.backend.Send(data)
+	case aciaStatus:
+		a.reset()
+	case aciaCommand:
+		a.command = data
+		a.updateIrq()
+	case aciaControl:
+		a.control = data
+		a.setBaudRate(data)
+		dataBits, stopBits := framing(data)
+		a.backend.SetFraming(dataBits, stopBits)
+	}
+}
+
+// framing decodes the control register's word-length (bits 5-6) and stop
+// bit count (bit 7) fields, per the 6551 datasheet.
+func framing(control byte) (dataBits, stopBits int) {
+	dataBits = controlWordLengths[(control&controlWordLenMask)>>controlWordLenShift]
+	if control&controlStopBits2 != 0 {
+		return dataBits, 2
+	}
+	return dataBits, 1
+}
+
+// status computes the status register; transmission is modelled as
+// synchronous, so TDRE is always set.
+func (a *Acia6551) status() byte {
+	s := byte(statusTdre)
+	if a.rxFull {
+		s |= statusRdrf
+	}
+	if a.overrun {
+		s |= statusOverrun
+	}
+	if a.irqCondition() {
+		s |= statusIrq
+	}
+	return s
+}
+
+// irqCondition reports whether RDRF is set and the receiver interrupt is
+// enabled (command register bit 0x02 clear).
+func (a *Acia6551) irqCondition() bool {
+	return a.rxFull && a.command&commandReceiverIrqDisable == 0
+}
+
+func (a *Acia6551) updateIrq() {
+	if a.irq == nil {
+		return
+	}
+	if a.irqCondition() {
+		a.irq.Assert()
+	} else {
+		a.irq.Release()
+	}
+}
+
+func (a *Acia6551) setBaudRate(control byte) {
+	baud := baudRates[control&controlBaudMask]
+	a.cyclesPerByte = busHz * 10 / baud
+}
+
+// reset restores power-on defaults, as writing the status register does on
+// a real 6551.
+func (a *Acia6551) reset() {
+	a.command = 0
+	a.control = 0
+	a.rxFull = false
+	a.overrun = false
+	a.cyclesSinceTick = 0
+	a.setBaudRate(0)
+	a.updateIrq()
+}
+
+// Tick advances the baud-rate clock by cycles and polls the backend for a
+// newly-arrived byte once a byte's worth of time has elapsed. A byte that
+// arrives while the previous one is still unread is dropped and recorded
+// as an overrun, rather than left buffered in the backend indefinitely.
+func (a *Acia6551) Tick(cycles uint64) {
+	a.cyclesSinceTick += cycles
+	if a.cyclesSinceTick < a.cyclesPerByte {
+		return
+	}
+	a.cyclesSinceTick -= a.cyclesPerByte
+
+	b, ok := a.backend.Recv()
+	if !ok {
+		return
+	}
+	if a.rxFull {
+		a.overrun = true
+		a.updateIrq()
+		return
+	}
+	a.rxData = b
+	a.rxFull = true
+	a.updateIrq()
+}
+
+// Size is the address size of the memory-mapped IO.
+// Helps to meet the go6502.Memory interface.
+func (a *Acia6551) Size() int {
+	return 4 // 2-bit RS exposes 4 bytes of address space.
+}
+
+func (a *Acia6551) String() string {
+	return fmt.Sprintf("ACIA6551@0x%04X", a.base)
+}
@@ -0,0 +1,164 @@
+package acia6551
+
+import "testing"
+
+// fakeBackend is a SerialBackend that records sent bytes and serves queued
+// received bytes, for testing the ACIA's register logic in isolation.
+type fakeBackend struct {
+	sent     []byte
+	toRecv   []byte
+	dataBits int
+	stopBits int
+}
+
+func (b *fakeBackend) Send(data byte) error {
+	b.sent = append(b.sent, data)
+	return nil
+}
+
+func (b *fakeBackend) Recv() (byte, bool) {
+	if len(b.toRecv) == 0 {
+		return 0, false
+	}
+	data := b.toRecv[0]
+	b.toRecv = b.toRecv[1:]
+	return data, true
+}
+
+func (b *fakeBackend) SetFraming(dataBits, stopBits int) {
+	b.dataBits = dataBits
+	b.stopBits = stopBits
+}
+
+func (b *fakeBackend) Close() error { return nil }
+
+// fakeIrqLine records Assert/Release calls.
+type fakeIrqLine struct {
+	asserted bool
+}
+
+func (f *fakeIrqLine) Assert()  { f.asserted = true }
+func (f *fakeIrqLine) Release() { f.asserted = false }
+
+func TestWriteDataSendsThroughBackend(t *testing.T) {
+	backend := &fakeBackend{}
+	a := NewAcia6551(backend, 0xD000)
+
+	a.Write(aciaData, 0x41)
+	if len(backend.sent) != 1 || backend.sent[0] != 0x41 {
+		t.Fatalf("backend.sent = %v, want [0x41]", backend.sent)
+	}
+	if a.Read(aciaStatus)&statusTdre == 0 {
+		t.Fatal("TDRE not set after a synchronous send")
+	}
+}
+
+func TestTickReceivesByteAndSetsRdrf(t *testing.T) {
+	backend := &fakeBackend{toRecv: []byte{0x58}}
+	a := NewAcia6551(backend, 0xD000)
+	a.Write(aciaControl, 0x0F) // fastest supported rate, for a short test
+
+	if a.Read(aciaStatus)&statusRdrf != 0 {
+		t.Fatal("RDRF set before any bytes arrived")
+	}
+
+	a.Tick(a.cyclesPerByte)
+	if a.Read(aciaStatus)&statusRdrf == 0 {
+		t.Fatal("RDRF not set after Tick() past a byte boundary")
+	}
+	if got := a.Read(aciaData); got != 0x58 {
+		t.Fatalf("Read(aciaData) = 0x%02X, want 0x58", got)
+	}
+	if a.Read(aciaStatus)&statusRdrf != 0 {
+		t.Fatal("RDRF not cleared by reading the data register")
+	}
+}
+
+func TestReceiverIrqAssertsOnRdrfWhenEnabled(t *testing.T) {
+	backend := &fakeBackend{toRecv: []byte{0x01}}
+	a := NewAcia6551(backend, 0xD000)
+	a.Write(aciaControl, 0x0F)
+	irq := &fakeIrqLine{}
+	a.AttachIRQ(irq)
+
+	a.Tick(a.cyclesPerByte)
+	if !irq.asserted {
+		t.Fatal("IRQ not asserted after RDRF with receiver interrupt enabled")
+	}
+
+	a.Read(aciaData)
+	if irq.asserted {
+		t.Fatal("IRQ still asserted after the byte was read")
+	}
+}
+
+func TestReceiverIrqDisabledByCommandRegister(t *testing.T) {
+	backend := &fakeBackend{toRecv: []byte{0x01}}
+	a := NewAcia6551(backend, 0xD000)
+	a.Write(aciaControl, 0x0F)
+	a.Write(aciaCommand, commandReceiverIrqDisable)
+	irq := &fakeIrqLine{}
+	a.AttachIRQ(irq)
+
+	a.Tick(a.cyclesPerByte)
+	if irq.asserted {
+		t.Fatal("IRQ asserted despite the receiver interrupt being disabled")
+	}
+	if a.Read(aciaStatus)&statusRdrf == 0 {
+		t.Fatal("RDRF should still be set even with the interrupt disabled")
+	}
+}
+
+func TestTickSetsOverrunWhenByteArrivesBeforePreviousIsRead(t *testing.T) {
+	backend := &fakeBackend{toRecv: []byte{0x11, 0x22}}
+	a := NewAcia6551(backend, 0xD000)
+	a.Write(aciaControl, 0x0F)
+
+	a.Tick(a.cyclesPerByte) // first byte arrives, RDRF set
+	if a.Read(aciaStatus)&statusOverrun != 0 {
+		t.Fatal("overrun set before a byte was dropped")
+	}
+
+	a.Tick(a.cyclesPerByte) // second byte arrives while the first is unread: dropped
+	if a.Read(aciaStatus)&statusOverrun == 0 {
+		t.Fatal("overrun not set after a byte arrived while RDRF was still set")
+	}
+	if got := a.Read(aciaData); got != 0x11 {
+		t.Fatalf("Read(aciaData) = 0x%02X, want the first byte (0x11) preserved", got)
+	}
+
+	if a.Read(aciaStatus)&statusOverrun != 0 {
+		t.Fatal("overrun not cleared by reading the status register")
+	}
+}
+
+func TestControlWriteNotifiesBackendOfFraming(t *testing.T) {
+	backend := &fakeBackend{}
+	a := NewAcia6551(backend, 0xD000)
+
+	a.Write(aciaControl, 0x0F|0x40|0x80) // 6-bit word (bits 5-6 = 10), 2 stop bits
+	if backend.dataBits != 6 {
+		t.Fatalf("backend.dataBits = %d, want 6", backend.dataBits)
+	}
+	if backend.stopBits != 2 {
+		t.Fatalf("backend.stopBits = %d, want 2", backend.stopBits)
+	}
+}
+
+func TestStatusWriteResets(t *testing.T) {
+	backend := &fakeBackend{toRecv: []byte{0x01}}
+	a := NewAcia6551(backend, 0xD000)
+	a.Write(aciaCommand, 0x0A)
+	a.Tick(a.cyclesPerByte)
+	if a.Read(aciaStatus)&statusRdrf == 0 {
+		t.Fatal("setup: RDRF should be set before reset")
+	}
+
+	a.Write(aciaStatus, 0x00) // any write to the status register resets
+	if a.Read(aciaCommand) != 0 {
+		t.Fatal("command register not cleared by status-register write")
+	}
+	if a.Read(aciaStatus)&statusRdrf != 0 {
+		t.Fatal("RDRF not cleared by status-register write")
+	}
+}
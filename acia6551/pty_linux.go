@@ -0,0 +1,60 @@
+//go:build linux
+
+package acia6551
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl requests for allocating a pty pair via /dev/ptmx, from
+// asm-generic/ioctls.h (TIOCGPTN, TIOCSPTLCK).
+const (
+	tiocgptn   = 0x80045430
+	tiocsptlck = 0x40045431
+)
+
+// PtySerialBackend connects the ACIA's serial port to a freshly allocated
+// pseudo-terminal, so another program (e.g. minicom, screen) can be
+// attached to the emulated machine's console as if it were a real serial
+// line.
+type PtySerialBackend struct {
+	*streamBackend
+	SlavePath string
+}
+
+// NewPtySerialBackend opens /dev/ptmx, unlocks and resolves its slave side,
+// and returns a backend streaming through the master side.
+func NewPtySerialBackend() (*PtySerialBackend, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var unlock int32
+	if err := ptyIoctl(master, tiocsptlck, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("acia6551: unlocking pty: %w", err)
+	}
+
+	var n uint32
+	if err := ptyIoctl(master, tiocgptn, unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("acia6551: resolving pty slave: %w", err)
+	}
+
+	return &PtySerialBackend{
+		streamBackend: newStreamBackend(master),
+		SlavePath:     fmt.Sprintf("/dev/pts/%d", n),
+	}, nil
+}
+
+func ptyIoctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
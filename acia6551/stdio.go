@@ -0,0 +1,78 @@
+package acia6551
+
+import (
+	"io"
+	"os"
+)
+
+// streamBackend is the shared plumbing behind StdioSerialBackend and
+// PtySerialBackend: both just read bytes from an io.Reader in the
+// background (so Recv never blocks the emulator's Tick) and write bytes
+// straight through.
+type streamBackend struct {
+	rw   io.ReadWriteCloser
+	recv chan byte
+	done chan struct{}
+}
+
+func newStreamBackend(rw io.ReadWriteCloser) *streamBackend {
+	b := &streamBackend{
+		rw:   rw,
+		recv: make(chan byte, 256),
+		done: make(chan struct{}),
+	}
+	go b.readLoop()
+	return b
+}
+
+func (b *streamBackend) readLoop() {
+	buf := make([]byte, 1)
+	for {
+		n, err := b.rw.Read(buf)
+		if n > 0 {
+			select {
+			case b.recv <- buf[0]:
+			case <-b.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *streamBackend) Send(data byte) error {
+	_, err := b.rw.Write([]byte{data})
+	return err
+}
+
+func (b *streamBackend) Recv() (byte, bool) {
+	select {
+	case data := <-b.recv:
+		return data, true
+	default:
+		return 0, false
+	}
+}
+
+// SetFraming is a no-op: both StdioSerialBackend and PtySerialBackend are
+// just byte streams (the process's own stdio, or a pty's master side)
+// with no framing of their own to update.
+func (b *streamBackend) SetFraming(dataBits, stopBits int) {}
+
+func (b *streamBackend) Close() error {
+	close(b.done)
+	return b.rw.Close()
+}
+
+// stdio adapts os.Stdin/os.Stdout to the single io.ReadWriteCloser
+// streamBackend expects, without letting Close() on the backend close the
+// process's actual stdin/stdout. StdioSerialBackend itself is platform
+// specific (see stdio_linux.go) since putting the terminal into cbreak mode
+// is done through OS-specific ioctls.
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdio) Close() error                { return nil }
@@ -0,0 +1,82 @@
+//go:build linux
+
+package acia6551
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl requests for getting/setting terminal attributes, from
+// asm-generic/ioctls.h (TCGETS, TCSETS).
+const (
+	tcgets = 0x5401
+	tcsets = 0x5402
+
+	lflagIcanon = 0x0002
+	lflagEcho   = 0x0008
+
+	ccVmin  = 6
+	ccVtime = 5
+)
+
+// termios mirrors the kernel's struct termios (asm-generic/termbits.h) -
+// just enough of it to flip ICANON/ECHO off and set VMIN/VTIME for cbreak
+// mode.
+type termios struct {
+	Iflag uint32
+	Oflag uint32
+	Cflag uint32
+	Lflag uint32
+	Line  uint8
+	Cc    [19]uint8
+}
+
+func termiosIoctl(fd uintptr, req uintptr, t *termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// StdioSerialBackend connects the ACIA's serial port to the process's own
+// stdin/stdout, so the emulated machine's console shows up as this
+// process's console. Stdin is put into cbreak mode (no line buffering or
+// local echo) so the emulated machine sees each keystroke as a real serial
+// terminal would; Ctrl-C still raises SIGINT since ISIG is left enabled,
+// for the signal handler in go6502.go. Close restores the original mode.
+type StdioSerialBackend struct {
+	*streamBackend
+	restore func()
+}
+
+// NewStdioSerialBackend puts os.Stdin into cbreak mode, starts reading it in
+// the background, buffering received bytes until Recv picks them up, and
+// writes to os.Stdout.
+func NewStdioSerialBackend() (*StdioSerialBackend, error) {
+	var original termios
+	if err := termiosIoctl(os.Stdin.Fd(), tcgets, &original); err != nil {
+		return nil, err
+	}
+
+	cbreak := original
+	cbreak.Lflag &^= lflagIcanon | lflagEcho
+	cbreak.Cc[ccVmin] = 1
+	cbreak.Cc[ccVtime] = 0
+	if err := termiosIoctl(os.Stdin.Fd(), tcsets, &cbreak); err != nil {
+		return nil, err
+	}
+
+	return &StdioSerialBackend{
+		streamBackend: newStreamBackend(stdio{}),
+		restore:       func() { termiosIoctl(os.Stdin.Fd(), tcsets, &original) },
+	}, nil
+}
+
+// Close restores stdin's original terminal mode before closing the
+// underlying stream.
+func (b *StdioSerialBackend) Close() error {
+	b.restore()
+	return b.streamBackend.Close()
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package acia6551
+
+// StdioSerialBackend connects the ACIA's serial port to the process's own
+// stdin/stdout, so the emulated machine's console shows up as this
+// process's console. Unlike the Linux build, stdin isn't put into cbreak
+// mode here (that's done through Linux-specific termios ioctls), so input
+// stays line-buffered and echoed by the terminal as usual.
+type StdioSerialBackend struct {
+	*streamBackend
+}
+
+// NewStdioSerialBackend starts reading os.Stdin in the background, buffering
+// received bytes until Recv picks them up, and writes to os.Stdout.
+func NewStdioSerialBackend() (*StdioSerialBackend, error) {
+	return &StdioSerialBackend{newStreamBackend(stdio{})}, nil
+}
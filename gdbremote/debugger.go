@@ -0,0 +1,218 @@
+package gdbremote
+
+import (
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Target is the execution surface a Debugger needs from a CPU (such as
+// go6502.Cpu, which isn't part of this tree) to serve GDB remote requests.
+// Continue and Step run synchronously and block until the target stops,
+// returning a GDB stop-reply signal (e.g. "S05" for SIGTRAP).
+type Target interface {
+	ReadRegisters() []byte
+	WriteRegisters(data []byte)
+
+	ReadMemory(addr uint16, length int) []byte
+	WriteMemory(addr uint16, data []byte)
+
+	Continue() (stopReply string)
+	Step() (stopReply string)
+
+	SetBreakpoint(addr uint16)
+	ClearBreakpoint(addr uint16)
+	SetWatchpoint(addr uint16)
+	ClearWatchpoint(addr uint16)
+}
+
+// Debugger serves GDB remote sessions against a Target.
+type Debugger struct {
+	target Target
+}
+
+// NewDebugger returns a Debugger that dispatches GDB remote commands to target.
+func NewDebugger(target Target) *Debugger {
+	return &Debugger{target: target}
+}
+
+// ListenGDB listens on addr and serves GDB remote sessions one at a time
+// until accepting a connection fails.
+func (d *Debugger) ListenGDB(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	return d.Accept(ln)
+}
+
+// Accept serves GDB remote sessions, one at a time, from connections to ln
+// until Accept fails (typically because ln was closed).
+func (d *Debugger) Accept(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		d.Serve(conn)
+	}
+}
+
+// Serve handles a single GDB remote session over rw until the client
+// disconnects, sends "k" (kill), or a read fails.
+func (d *Debugger) Serve(rw io.ReadWriteCloser) {
+	defer rw.Close()
+	conn := NewConn(rw)
+	for {
+		payload, err := conn.ReadPacket()
+		if err == ErrBadChecksum {
+			continue // nak sent; await the retransmission
+		}
+		if err != nil {
+			return
+		}
+		reply, noAck, kill := d.dispatch(payload)
+		if noAck {
+			conn.SetNoAck(true)
+		}
+		if reply != "" {
+			if err := conn.WritePacket(reply); err != nil {
+				return
+			}
+		}
+		if kill {
+			return
+		}
+	}
+}
+
+// dispatch interprets a single request payload and returns the reply to
+// send (empty for "unsupported", per the GDB spec), whether no-ack mode
+// should now be enabled, and whether the session should end.
+func (d *Debugger) dispatch(payload string) (reply string, noAck bool, kill bool) {
+	switch {
+	case payload == "?":
+		return "S05", false, false
+
+	case payload == "g":
+		return hex.EncodeToString(d.target.ReadRegisters()), false, false
+
+	case strings.HasPrefix(payload, "G"):
+		data, err := hex.DecodeString(payload[1:])
+		if err != nil {
+			return "E01", false, false
+		}
+		d.target.WriteRegisters(data)
+		return "OK", false, false
+
+	case strings.HasPrefix(payload, "m"):
+		addr, length, ok := parseMemRead(payload[1:])
+		if !ok {
+			return "E01", false, false
+		}
+		return hex.EncodeToString(d.target.ReadMemory(addr, length)), false, false
+
+	case strings.HasPrefix(payload, "M"):
+		addr, data, ok := parseMemWrite(payload[1:])
+		if !ok {
+			return "E01", false, false
+		}
+		d.target.WriteMemory(addr, data)
+		return "OK", false, false
+
+	case payload == "s":
+		return d.target.Step(), false, false
+
+	case payload == "c":
+		return d.target.Continue(), false, false
+
+	case strings.HasPrefix(payload, "Z0,"), strings.HasPrefix(payload, "Z2,"):
+		addr, ok := parseBreakOrWatch(payload[3:])
+		if !ok {
+			return "E01", false, false
+		}
+		if payload[1] == '0' {
+			d.target.SetBreakpoint(addr)
+		} else {
+			d.target.SetWatchpoint(addr)
+		}
+		return "OK", false, false
+
+	case strings.HasPrefix(payload, "z0,"), strings.HasPrefix(payload, "z2,"):
+		addr, ok := parseBreakOrWatch(payload[3:])
+		if !ok {
+			return "E01", false, false
+		}
+		if payload[1] == '0' {
+			d.target.ClearBreakpoint(addr)
+		} else {
+			d.target.ClearWatchpoint(addr)
+		}
+		return "OK", false, false
+
+	case payload == "k":
+		return "", false, true
+
+	case strings.HasPrefix(payload, "qSupported"):
+		return "PacketSize=1000;QStartNoAckMode+", false, false
+
+	case payload == "qAttached":
+		return "1", false, false
+
+	case payload == "QStartNoAckMode":
+		return "OK", true, false
+
+	default:
+		return "", false, false // unsupported request: empty reply, per spec
+	}
+}
+
+// parseMemRead parses an "addr,length" argument (both hex) for the m packet.
+func parseMemRead(args string) (addr uint16, length int, ok bool) {
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	a, err := strconv.ParseUint(parts[0], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	l, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint16(a), int(l), true
+}
+
+// parseMemWrite parses an "addr,length:data" argument for the M packet.
+func parseMemWrite(args string) (addr uint16, data []byte, ok bool) {
+	head, hexData, found := strings.Cut(args, ":")
+	if !found {
+		return 0, nil, false
+	}
+	a, _, parsedOK := parseMemRead(head)
+	if !parsedOK {
+		return 0, nil, false
+	}
+	data, err := hex.DecodeString(hexData)
+	if err != nil {
+		return 0, nil, false
+	}
+	return a, data, true
+}
+
+// parseBreakOrWatch parses the "addr,kind" argument shared by Z/z packets.
+func parseBreakOrWatch(args string) (addr uint16, ok bool) {
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	a, err := strconv.ParseUint(parts[0], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(a), true
+}
@@ -0,0 +1,212 @@
+package gdbremote
+
+import (
+	"net"
+	"testing"
+)
+
+// fakeTarget is a minimal in-memory Target for exercising Debugger's
+// command dispatch without a real CPU.
+type fakeTarget struct {
+	registers   []byte
+	memory      map[uint16]byte
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]bool
+}
+
+func newFakeTarget() *fakeTarget {
+	return &fakeTarget{
+		registers:   []byte{0x01, 0x02, 0x03},
+		memory:      make(map[uint16]byte),
+		breakpoints: make(map[uint16]bool),
+		watchpoints: make(map[uint16]bool),
+	}
+}
+
+func (t *fakeTarget) ReadRegisters() []byte { return t.registers }
+func (t *fakeTarget) WriteRegisters(data []byte) {
+	t.registers = append([]byte(nil), data...)
+}
+
+func (t *fakeTarget) ReadMemory(addr uint16, length int) []byte {
+	out := make([]byte, length)
+	for i := 0; i < length; i++ {
+		out[i] = t.memory[addr+uint16(i)]
+	}
+	return out
+}
+
+func (t *fakeTarget) WriteMemory(addr uint16, data []byte) {
+	for i, b := range data {
+		t.memory[addr+uint16(i)] = b
+	}
+}
+
+func (t *fakeTarget) Continue() string { return "S05" }
+func (t *fakeTarget) Step() string     { return "S05" }
+
+func (t *fakeTarget) SetBreakpoint(addr uint16)   { t.breakpoints[addr] = true }
+func (t *fakeTarget) ClearBreakpoint(addr uint16) { delete(t.breakpoints, addr) }
+func (t *fakeTarget) SetWatchpoint(addr uint16)   { t.watchpoints[addr] = true }
+func (t *fakeTarget) ClearWatchpoint(addr uint16) { delete(t.watchpoints, addr) }
+
+func TestDispatchReadAndWriteRegisters(t *testing.T) {
+	target := newFakeTarget()
+	d := NewDebugger(target)
+
+	reply, _, _ := d.dispatch("g")
+	if reply != "010203" {
+		t.Fatalf("g reply = %q, want %q", reply, "010203")
+	}
+
+	reply, _, _ = d.dispatch("Gaabbcc")
+	if reply != "OK" {
+		t.Fatalf("G reply = %q, want OK", reply)
+	}
+	if string(target.registers) != "\xaa\xbb\xcc" {
+		t.Fatalf("registers = %x, want aabbcc", target.registers)
+	}
+}
+
+func TestDispatchReadAndWriteMemory(t *testing.T) {
+	target := newFakeTarget()
+	d := NewDebugger(target)
+
+	reply, _, _ := d.dispatch("M1000,2:cafe")
+	if reply != "OK" {
+		t.Fatalf("M reply = %q, want OK", reply)
+	}
+
+	reply, _, _ = d.dispatch("m1000,2")
+	if reply != "cafe" {
+		t.Fatalf("m reply = %q, want %q", reply, "cafe")
+	}
+}
+
+func TestDispatchBreakpointsAndWatchpoints(t *testing.T) {
+	target := newFakeTarget()
+	d := NewDebugger(target)
+
+	if reply, _, _ := d.dispatch("Z0,1234,1"); reply != "OK" {
+		t.Fatalf("Z0 reply = %q, want OK", reply)
+	}
+	if !target.breakpoints[0x1234] {
+		t.Fatal("breakpoint not set at 0x1234")
+	}
+	if reply, _, _ := d.dispatch("z0,1234,1"); reply != "OK" {
+		t.Fatalf("z0 reply = %q, want OK", reply)
+	}
+	if target.breakpoints[0x1234] {
+		t.Fatal("breakpoint still set after z0")
+	}
+
+	if reply, _, _ := d.dispatch("Z2,2000,1"); reply != "OK" {
+		t.Fatalf("Z2 reply = %q, want OK", reply)
+	}
+	if !target.watchpoints[0x2000] {
+		t.Fatal("watchpoint not set at 0x2000")
+	}
+}
+
+func TestDispatchStepContinueAndKill(t *testing.T) {
+	target := newFakeTarget()
+	d := NewDebugger(target)
+
+	if reply, _, _ := d.dispatch("s"); reply != "S05" {
+		t.Fatalf("s reply = %q, want S05", reply)
+	}
+	if reply, _, _ := d.dispatch("c"); reply != "S05" {
+		t.Fatalf("c reply = %q, want S05", reply)
+	}
+	if _, _, kill := d.dispatch("k"); !kill {
+		t.Fatal("k should request session end")
+	}
+}
+
+func TestDispatchQSupportedAndNoAckMode(t *testing.T) {
+	d := NewDebugger(newFakeTarget())
+
+	reply, _, _ := d.dispatch("qSupported:multiprocess+")
+	if reply != "PacketSize=1000;QStartNoAckMode+" {
+		t.Fatalf("qSupported reply = %q", reply)
+	}
+
+	reply, noAck, _ := d.dispatch("QStartNoAckMode")
+	if reply != "OK" || !noAck {
+		t.Fatalf("QStartNoAckMode reply = %q, noAck = %v, want OK/true", reply, noAck)
+	}
+}
+
+func TestDispatchUnsupportedRequestGetsEmptyReply(t *testing.T) {
+	d := NewDebugger(newFakeTarget())
+	reply, _, kill := d.dispatch("vMustReplyEmpty")
+	if reply != "" || kill {
+		t.Fatalf("reply = %q, kill = %v, want empty/false", reply, kill)
+	}
+}
+
+// TestServeOverRealTCP is an integration test: a scripted client drives a
+// real Debugger, serving a fakeTarget, over an actual TCP socket (not just
+// an in-memory pipe).
+func TestServeOverRealTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	target := newFakeTarget()
+	d := NewDebugger(target)
+	go d.Accept(ln)
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	client := NewConn(clientConn)
+
+	if err := client.WritePacket("qSupported:multiprocess+"); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := client.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "PacketSize=1000;QStartNoAckMode+" {
+		t.Fatalf("qSupported reply = %q", reply)
+	}
+
+	if err := client.WritePacket("QStartNoAckMode"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err = client.ReadPacket(); err != nil || reply != "OK" {
+		t.Fatalf("QStartNoAckMode reply = %q, err = %v", reply, err)
+	}
+	client.SetNoAck(true)
+
+	if err := client.WritePacket("?"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err = client.ReadPacket(); err != nil || reply != "S05" {
+		t.Fatalf("? reply = %q, err = %v", reply, err)
+	}
+
+	if err := client.WritePacket("M2000,2:beef"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err = client.ReadPacket(); err != nil || reply != "OK" {
+		t.Fatalf("M reply = %q, err = %v", reply, err)
+	}
+
+	if err := client.WritePacket("m2000,2"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err = client.ReadPacket(); err != nil || reply != "beef" {
+		t.Fatalf("m reply = %q, err = %v", reply, err)
+	}
+
+	if err := client.WritePacket("k"); err != nil {
+		t.Fatal(err)
+	}
+}
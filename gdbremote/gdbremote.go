@@ -0,0 +1,132 @@
+// Package gdbremote implements the GDB Remote Serial Protocol:
+// https://sourceware.org/gdb/onlinedocs/gdb/Remote-Protocol.html
+//
+// A packet on the wire looks like "$payload#cc", where cc is the two-digit
+// lowercase hex checksum of payload (the sum of its bytes, mod 256). The
+// receiver acknowledges a well-formed packet with '+' and a corrupt one with
+// '-', which prompts the sender to retransmit, unless QStartNoAckMode has
+// turned that off (see Conn.SetNoAck). Conn handles that framing; Debugger
+// listens on a TCP socket and dispatches the command set (g/G/m/M/s/c/Z/z/
+// qSupported/qAttached/k) against a Target, see debugger.go.
+package gdbremote
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// interrupt is the out-of-band Ctrl-C byte GDB sends to request that a
+// running target stop, outside of the normal $...#cc packet framing.
+const interrupt = 0x03
+
+// Conn frames GDB remote serial protocol packets over an underlying
+// connection, handling checksums and +/- acknowledgement.
+type Conn struct {
+	r     *bufio.Reader
+	w     io.Writer
+	noAck bool
+}
+
+// NewConn wraps rw for packet-level reads and writes.
+func NewConn(rw io.ReadWriter) *Conn {
+	return &Conn{r: bufio.NewReader(rw), w: rw}
+}
+
+// SetNoAck disables +/- acknowledgement, per the "QStartNoAckMode" packet:
+// once the client's QStartNoAckMode request has been replied to with "OK",
+// both ends stop sending and expecting acks.
+func (c *Conn) SetNoAck(noAck bool) {
+	c.noAck = noAck
+}
+
+// ReadPacket reads the next packet, replying '+' once its checksum is
+// verified (or '-' if it's corrupt, in which case the caller should call
+// ReadPacket again to await the sender's retransmission). It returns the
+// packet's payload with the framing and checksum stripped.
+//
+// A lone 0x03 (the Ctrl-C interrupt byte GDB uses to stop a running target)
+// is returned as ErrInterrupt instead of a payload.
+func (c *Conn) ReadPacket() (string, error) {
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case interrupt:
+			return "", ErrInterrupt
+		case '$':
+			return c.readFramedPacket()
+		case '+', '-':
+			// Ack/nak for a packet we sent; nothing to do here.
+		default:
+			// Stray byte between packets; GDB doesn't send these in
+			// practice, but ignore rather than erroring.
+		}
+	}
+}
+
+func (c *Conn) readFramedPacket() (string, error) {
+	payload, err := c.r.ReadString('#')
+	if err != nil {
+		return "", err
+	}
+	payload = payload[:len(payload)-1] // drop the trailing '#'
+
+	checksumHex := make([]byte, 2)
+	if _, err := io.ReadFull(c.r, checksumHex); err != nil {
+		return "", err
+	}
+
+	if fmt.Sprintf("%02x", Checksum(payload)) != string(checksumHex) {
+		if !c.noAck {
+			if _, err := c.w.Write([]byte{'-'}); err != nil {
+				return "", err
+			}
+		}
+		return "", ErrBadChecksum
+	}
+
+	if !c.noAck {
+		if _, err := c.w.Write([]byte{'+'}); err != nil {
+			return "", err
+		}
+	}
+	return payload, nil
+}
+
+// WritePacket frames payload as "$payload#cc", writes it, and waits for the
+// receiver's +/- acknowledgement (unless SetNoAck(true) is in effect).
+func (c *Conn) WritePacket(payload string) error {
+	if _, err := fmt.Fprintf(c.w, "$%s#%02x", payload, Checksum(payload)); err != nil {
+		return err
+	}
+	if c.noAck {
+		return nil
+	}
+	ack, err := c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if ack != '+' {
+		return ErrBadChecksum
+	}
+	return nil
+}
+
+// Checksum is the GDB remote serial protocol checksum: the sum of payload's
+// bytes, mod 256.
+func Checksum(payload string) byte {
+	var sum byte
+	for i := 0; i < len(payload); i++ {
+		sum += payload[i]
+	}
+	return sum
+}
+
+// sentinel errors returned by Conn.ReadPacket.
+var (
+	ErrInterrupt   = fmt.Errorf("gdbremote: received Ctrl-C interrupt byte")
+	ErrBadChecksum = fmt.Errorf("gdbremote: packet failed checksum")
+)
@@ -5,11 +5,16 @@ import (
 	"os"
 	"os/signal"
 
+	"github.com/pda/go6502/acia6551"
+	"github.com/pda/go6502/gdbremote"
 	"github.com/pda/go6502/go6502"
+	"github.com/pda/go6502/via6522"
 )
 
 const (
-	kernalPath = "rom/kernal.rom"
+	kernalPath    = "rom/kernal.rom"
+	gdbServerAddr = ":1234"
+	aciaBase      = 0xD000
 )
 
 func main() {
@@ -27,7 +32,10 @@ func mainReturningStatus() int {
 
 	ram := &go6502.Ram{}
 
-	via := go6502.NewVia6522(options)
+	via := via6522.NewVia6522(via6522.Options{
+		DumpBinary: options.DumpBinary,
+		DumpAscii:  options.DumpAscii,
+	})
 	if options.ViaSsd1306 {
 		ssd1306 := go6502.NewSsd1306()
 		defer ssd1306.Close()
@@ -36,27 +44,67 @@ func mainReturningStatus() int {
 
 	via.Reset()
 
+	var acia *acia6551.Acia6551
+	if options.Acia {
+		var backend acia6551.SerialBackend
+		var err error
+		if options.AciaPty {
+			backend, err = acia6551.NewPtySerialBackend()
+		} else {
+			backend, err = acia6551.NewStdioSerialBackend()
+		}
+		if err != nil {
+			panic(err)
+		}
+		acia = acia6551.NewAcia6551(backend, aciaBase)
+		defer acia.Shutdown()
+	}
+
 	addressBus, _ := go6502.CreateBus()
 	addressBus.Attach(ram, "ram", 0x0000)
 	addressBus.Attach(via, "VIA", 0xC000)
 	addressBus.Attach(kernal, "kernal", 0xE000)
+	if acia != nil {
+		addressBus.Attach(acia, "ACIA", aciaBase)
+	}
 
 	exitChan := make(chan int, 0)
 
 	cpu := &go6502.Cpu{Bus: addressBus, ExitChan: exitChan}
+
+	// VIA and ACIA share the CPU's single /IRQ pin, so they can't both
+	// AttachIRQ(cpu.IRQ) directly: one releasing its own condition would
+	// incorrectly drop the line while the other still holds it. Route both
+	// through an aggregator that only releases once every source has.
+	irqs := via6522.NewIrqAggregator(cpu.IRQ)
+	via.AttachIRQ(irqs.NewSource())
+	if acia != nil {
+		acia.AttachIRQ(irqs.NewSource())
+	}
 	if options.Debug {
 		debugger := go6502.NewDebugger(cpu)
 		defer debugger.Close()
 		debugger.QueueCommands(options.DebugCmds)
 		cpu.AttachDebugger(debugger)
 	}
+	if options.GdbServer {
+		addr := options.GdbServerAddr
+		if addr == "" {
+			addr = gdbServerAddr
+		}
+		go gdbremote.NewDebugger(cpu).ListenGDB(addr)
+	}
 	cpu.Reset()
 
 	// Dispatch CPU in a goroutine.
 	go func() {
 		i := 0
 		for {
-			cpu.Step()
+			cycles := cpu.Step()
+			via.Tick(cycles)
+			if acia != nil {
+				acia.Tick(cycles)
+			}
 			i++
 		}
 	}()
@@ -0,0 +1,62 @@
+package via6522
+
+// IrqAggregator combines Assert()/Release() calls from multiple independent
+// IrqLine sources (for example a VIA and an ACIA sharing a 6502's single
+// /IRQ pin) into one line: asserted for as long as at least one source is
+// asserting, released only once every source has released. Giving each
+// peripheral its own Source via NewSource avoids the bug of handing the same
+// IrqLine to two AttachIRQ calls, where one peripheral's Release() would
+// incorrectly drop the line while another peripheral still wants it held.
+//
+// This only covers the peripheral side of interrupts: OR-ing sources onto
+// one pin. It does not vector a CPU through 0xFFFE/0xFFFA, distinguish BRK
+// from a hardware IRQ, or edge-trigger NMI - that's CPU-internal behaviour
+// that belongs in go6502.Cpu, which isn't part of this tree.
+type IrqAggregator struct {
+	target  IrqLine
+	sources map[*irqSource]bool
+}
+
+// NewIrqAggregator returns an IrqAggregator that forwards its combined,
+// OR'd state to target (typically a CPU's shared IRQ line).
+func NewIrqAggregator(target IrqLine) *IrqAggregator {
+	return &IrqAggregator{
+		target:  target,
+		sources: make(map[*irqSource]bool),
+	}
+}
+
+// NewSource returns an IrqLine a single peripheral can AttachIRQ to.
+// Asserting or releasing it updates the aggregate without affecting any
+// other source sharing the same IrqAggregator.
+func (agg *IrqAggregator) NewSource() IrqLine {
+	return &irqSource{agg: agg}
+}
+
+// Asserted reports whether any attached source currently holds the line.
+func (agg *IrqAggregator) Asserted() bool {
+	return len(agg.sources) > 0
+}
+
+func (agg *IrqAggregator) set(s *irqSource, asserted bool) {
+	was := agg.Asserted()
+	if asserted {
+		agg.sources[s] = true
+	} else {
+		delete(agg.sources, s)
+	}
+	if now := agg.Asserted(); now != was {
+		if now {
+			agg.target.Assert()
+		} else {
+			agg.target.Release()
+		}
+	}
+}
+
+type irqSource struct {
+	agg *IrqAggregator
+}
+
+func (s *irqSource) Assert()  { s.agg.set(s, true) }
+func (s *irqSource) Release() { s.agg.set(s, false) }
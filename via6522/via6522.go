@@ -1,61 +1,98 @@
 /*
-	Package via6522 emulates MOS Technology 6522, or the modern WDC 65C22.
-	This is a Versatile Interface Adapter (VIA) I/O controller
-	designed for use with the 6502 microprocessor.
-
-	The 4-bit RS (register select) is exposed as 16 bytes of address-space.  The
-	processor chooses the register using four bits of the 16-bit address bus and
-	reads/writes using the 8-bit data bus.
-
-	Peripheral ports
-
-	The W65C22 includes functions for programmed control of two peripheral ports
-	(Ports A and B). Two program controlled 8-bit bidirectional peripheral I/O
-	ports allow direct interfacing between the microprocessor and selected
-	peripheral units. Each port has input data latching capability. Two
-	programmable Data Direction Registers (A and B) allow selection of data
-	direction (input or output) on an individual line basis.
-
-	RS registers relevant to peripheral ports:
-	(a register is selected by setting an address to the 4-bit RS lines)
-		0x00: ORB/IRB; write: Output Register B, read: Input Register "B".
-		0x01: ORA/IRA; write: Output Register A, read: Input Register "A".
-		0x02: DDRB; Data Direction Register B
-		0x03: DDRA; Data Direction Register A
-		0x0C: PCR; Peripheral Control Register.
-		      0: CA1 control, 1..3: CA2 control
-		      4: CB1 control, 5..7: CB2 control.
-
-	External interface relevant to peripheral ports:
-	PORTA: 8-bit independently bidirectional data to peripheral.
-	PORTB: 8-bit independently bidirectional data to peripheral.
-	DATA: 8-bit bidirectional data to microprocessor.
-	RS: 4-bit register select.
-	CA: 2-bit control lines for PORTA.
-	CB: 2-bit control lines for PORTB.
-
-	Write handshake control (PORT A as example, PORT B is same for writes):
-	  CA2 (output) indicates data has been written to ORA and is ready.
-	  CA1 (input) indicates data has been taken.
-	Default modes assuming PCR == 0x00:
-	  CA2: Input-negative active edge (one of eight options).
-	  CA1: negative active edge (one of two options).
-
-	Timers
-
-	Timers have not yet been implemented.
-
-	Interrupts
-
-	Interrupts have not yet been implemented.
-
-	Reference Material
-
-	The following data sheets and external resources may be useful.
-
-		Original 6522: http://en.wikipedia.org/wiki/MOS_Technology_6522
-		WCD 65C22: http://www.westerndesigncenter.com/wdc/w65c22-chip.cfm
-		Data sheet: http://www.westerndesigncenter.com/wdc/documentation/w65c22.pdf
+Package via6522 emulates MOS Technology 6522, or the modern WDC 65C22.
+This is a Versatile Interface Adapter (VIA) I/O controller
+designed for use with the 6502 microprocessor.
+
+The 4-bit RS (register select) is exposed as 16 bytes of address-space.  The
+processor chooses the register using four bits of the 16-bit address bus and
+reads/writes using the 8-bit data bus.
+
+# Peripheral ports
+
+The W65C22 includes functions for programmed control of two peripheral ports
+(Ports A and B). Two program controlled 8-bit bidirectional peripheral I/O
+ports allow direct interfacing between the microprocessor and selected
+peripheral units. Each port has input data latching capability. Two
+programmable Data Direction Registers (A and B) allow selection of data
+direction (input or output) on an individual line basis.
+
+RS registers relevant to peripheral ports:
+(a register is selected by setting an address to the 4-bit RS lines)
+
+	0x00: ORB/IRB; write: Output Register B, read: Input Register "B".
+	0x01: ORA/IRA; write: Output Register A, read: Input Register "A".
+	0x02: DDRB; Data Direction Register B
+	0x03: DDRA; Data Direction Register A
+	0x0C: PCR; Peripheral Control Register.
+	      0: CA1 control, 1..3: CA2 control
+	      4: CB1 control, 5..7: CB2 control.
+
+External interface relevant to peripheral ports:
+PORTA: 8-bit independently bidirectional data to peripheral.
+PORTB: 8-bit independently bidirectional data to peripheral.
+DATA: 8-bit bidirectional data to microprocessor.
+RS: 4-bit register select.
+CA: 2-bit control lines for PORTA.
+CB: 2-bit control lines for PORTB.
+
+Write handshake control (PORT A as example, PORT B is same for writes):
+
+	CA2 (output) indicates data has been written to ORA and is ready.
+	CA1 (input) indicates data has been taken.
+
+Default modes assuming PCR == 0x00:
+
+	CA2: Input-negative active edge (one of eight options).
+	CA1: negative active edge (one of two options).
+
+CA2/CB2 handshake mode (control mode 0x4 in PCR bits 1..3 or 5..7) and
+pulse mode (control mode 0x5) are driven
+directly: writing ORA/ORB, or reading IRA, pulses CA2/CB2 low (one cycle
+in pulse mode, held in handshake mode). A HandshakingPeripheral is told
+about the pulse via Strobe(), and calls AckA()/AckB() back once it has
+taken (or supplied) the data; that sets the CA1/CB1 IFR bit and, in
+handshake mode, releases the held CA2/CB2 line.
+
+# Timers
+
+T1 and T2 provide cycle-counted timing, driven by calling Tick() with the
+number of CPU cycles consumed by the most recently executed instruction.
+
+T1 is a 16-bit counter (T1C-L/T1C-H) backed by a 16-bit latch (T1L-L/T1L-H).
+Writing T1C-H loads the latch into the counter and starts it running.
+In one-shot mode (ACR bit 6 clear) T1 interrupts once as it passes through
+zero and then free-runs until reloaded. In free-running mode (ACR bit 6
+set) T1 reloads itself from the latch on every underflow, interrupting
+each time; if ACR bit 7 is also set, PB7 is toggled on each underflow.
+
+T2 is a 16-bit counter (T2C-L/T2C-H) with only a low-order latch
+(T2L-L); writing T2C-H loads the latch into the counter's low byte and
+the written value into the counter's high byte. In one-shot mode (ACR
+bit 5 clear) T2 counts Tick() cycles and interrupts once on underflow.
+In pulse-counting mode (ACR bit 5 set) T2 instead decrements once per
+negative edge on PB6, delivered by calling PulsePB6(); Tick() does not
+advance T2 in this mode.
+
+# Interrupts
+
+IFR (0x0D) latches pending interrupt conditions; IER (0x0E) selects
+which of them are allowed to assert the VIA's IRQ output, reported to
+callers via IRQ(). T1 sets IFR bit 0x40 on underflow; reading T1C-L or
+writing T1C-H clears it. T2 sets IFR bit 0x20 on underflow; reading
+T2C-L or writing T2C-H clears it. Writes to IER with bit 7 set enable
+the written bits; writes with bit 7 clear disable them.
+
+AttachIRQ connects the VIA's interrupt output to an IRQ line, typically a
+source from an IrqAggregator shared with other peripherals: Assert() is
+called while IFR&IER is non-zero, and Release() while it is zero.
+
+# Reference Material
+
+The following data sheets and external resources may be useful.
+
+	Original 6522: http://en.wikipedia.org/wiki/MOS_Technology_6522
+	WCD 65C22: http://www.westerndesigncenter.com/wdc/w65c22-chip.cfm
+	Data sheet: http://www.westerndesigncenter.com/wdc/documentation/w65c22.pdf
 */
 package via6522
 
@@ -74,9 +111,39 @@ const (
 	viaDdrb = 0x2
 	viaDdra = 0x3
 
+	viaT1cl = 0x4
+	viaT1ch = 0x5
+	viaT1ll = 0x6
+	viaT1lh = 0x7
+	viaT2cl = 0x8
+	viaT2ch = 0x9
+
+	viaAcr = 0xB
+	viaPcr = 0xC
+	viaIfr = 0xD
+	viaIer = 0xE
+
 	// bit-offset into PCR for port A & B
 	viaPcrOffsetA = 0
 	viaPcrOffsetB = 4
+
+	// ACR bits governing timer behaviour.
+	viaAcrT2PulseCounting = 1 << 5 // 0: T2 is a one-shot cycle counter. 1: T2 counts PB6 pulses.
+	viaAcrT1FreeRun       = 1 << 6 // 0: T1 is one-shot. 1: T1 reloads from latch on underflow.
+	viaAcrT1Pb7           = 1 << 7 // 1: T1 toggles PB7 on underflow.
+
+	// IFR/IER bits for the two timers.
+	viaIfrT2       = 1 << 5
+	viaIfrT1       = 1 << 6
+	viaIerSetClear = 1 << 7 // IER write: 1 = set listed bits, 0 = clear listed bits.
+
+	// IFR bits set by a CA1/CB1 acknowledgement edge.
+	viaIfrCa1 = 1 << 1
+	viaIfrCb1 = 1 << 4
+
+	// control2Mode() values relevant to handshaking; the rest are input modes.
+	viaControl2Handshake = 0x4 // CA2/CB2 held low until the matching CA1/CB1 edge.
+	viaControl2Pulse     = 0x5 // CA2/CB2 pulsed low for one Tick() cycle.
 )
 
 /**
@@ -88,16 +155,32 @@ const (
 type Via6522 struct {
 	// Note: It may be a mistake to consider ORx and IRx separate registers.
 	//       If so... fix it?
-	ora           byte // output register port A
-	orb           byte // output register port B
-	ira           byte // input register port A
-	irb           byte // input register port B
-	ddra          byte // data direction port A
-	ddrb          byte // data direction port B
-	pcr           byte // peripheral control register
-	options       Options
-	paPeripherals []ParallelPeripheral
-	pbPeripherals []ParallelPeripheral
+	ora            byte   // output register port A
+	orb            byte   // output register port B
+	ira            byte   // input register port A
+	irb            byte   // input register port B
+	ddra           byte   // data direction port A
+	ddrb           byte   // data direction port B
+	pcr            byte   // peripheral control register
+	acr            byte   // auxiliary control register
+	ifr            byte   // interrupt flag register
+	ier            byte   // interrupt enable register
+	t1c            uint16 // T1 counter
+	t1l            uint16 // T1 latch
+	t1OneShotFired bool   // true once T1 has asserted its IFR bit since the last T1C-H write, in one-shot mode
+	t2c            uint16 // T2 counter
+	t2l            byte   // T2 low-order latch
+	pb7            byte   // T1-driven PB7 toggle state (0x00 or 0x80), see viaAcrT1Pb7
+	ca2Held        bool   // CA2 held low, awaiting AckA() (viaControl2Handshake)
+	cb2Held        bool   // CB2 held low, awaiting AckB() (viaControl2Handshake)
+	ca2Pulse       bool   // CA2 pulsed low this cycle, released on next Tick (viaControl2Pulse)
+	cb2Pulse       bool   // CB2 pulsed low this cycle, released on next Tick (viaControl2Pulse)
+	ca1Level       byte   // simulated CA1 input level (0 or 1), idles high; see AckA
+	cb1Level       byte   // simulated CB1 input level (0 or 1), idles high; see AckB
+	irq            IrqLine
+	options        Options
+	paPeripherals  []ParallelPeripheral
+	pbPeripherals  []ParallelPeripheral
 }
 
 type Options struct {
@@ -105,6 +188,16 @@ type Options struct {
 	DumpAscii  bool
 }
 
+// IrqLine is the shared, aggregated interrupt line exposed by a CPU (such as
+// go6502.Cpu's IRQ line). Peripherals assert it while they have a pending,
+// enabled interrupt condition, and release it once that condition clears;
+// peripherals sharing one CPU line each attach to their own IrqAggregator
+// source, which ORs them together.
+type IrqLine interface {
+	Assert()
+	Release()
+}
+
 // ParallelPeripheral defines an interface for peripheral devices which can connect to
 // either of the parallel ports to read and write data.
 type ParallelPeripheral interface {
@@ -127,6 +220,19 @@ type ParallelPeripheral interface {
 	String() string
 }
 
+// HandshakingPeripheral is a ParallelPeripheral that participates in
+// CA2/CB2-CA1/CB1 handshaking (PCR handshake or pulse mode, viaControl2Handshake
+// or viaControl2Pulse) instead of plain bit-banging.
+type HandshakingPeripheral interface {
+	ParallelPeripheral
+
+	// Strobe is called when the VIA pulses CA2 (port A) or CB2 (port B)
+	// low to indicate data is ready (write) or has been taken (read of
+	// IRA). The peripheral should call back via.AckA() or via.AckB(),
+	// synchronously or later, once it has handled the strobe.
+	Strobe()
+}
+
 func NewVia6522(o Options) *Via6522 {
 	via := &Via6522{}
 	via.options = o
@@ -147,6 +253,13 @@ func (via *Via6522) AttachToPortB(p ParallelPeripheral) {
 	via.pbPeripherals = append(via.pbPeripherals, p)
 }
 
+// AttachIRQ connects the VIA's IFR/IER-driven interrupt output to the CPU's
+// shared IRQ line.
+func (via *Via6522) AttachIRQ(irq IrqLine) {
+	via.irq = irq
+	via.updateIrq()
+}
+
 // Shutdown tells Via6522 and its devices that the system is shutting down.
 func (via *Via6522) Shutdown() {
 	var p ParallelPeripheral
@@ -170,6 +283,79 @@ func (via *Via6522) control2Mode(portOffset uint8) byte {
 	return (via.pcr >> (portOffset + 1)) & 0x7
 }
 
+// AckA is called by a HandshakingPeripheral attached to port A once it has
+// handled a Strobe(). The peripheral is expected to toggle CA1 in response;
+// we simulate that toggle here and only pulse CA1's IFR flag (and, in
+// handshake mode, release CA2) if the transition matches the active edge
+// configured in PCR bit 0 (0 = negative edge, 1 = positive edge).
+func (via *Via6522) AckA() {
+	next := via.ca1Level ^ 1
+	activeEdge := via.control1Mode(viaPcrOffsetA)
+	if next == activeEdge {
+		via.ca2Held = false
+		via.setIfr(viaIfrCa1)
+	}
+	via.ca1Level = next
+}
+
+// AckB is called by a HandshakingPeripheral attached to port B once it has
+// handled a Strobe(). See AckA; the active edge is configured in PCR bit 4.
+func (via *Via6522) AckB() {
+	next := via.cb1Level ^ 1
+	activeEdge := via.control1Mode(viaPcrOffsetB)
+	if next == activeEdge {
+		via.cb2Held = false
+		via.setIfr(viaIfrCb1)
+	}
+	via.cb1Level = next
+}
+
+// CA2Low reports whether CA2 is currently being held or pulsed low.
+func (via *Via6522) CA2Low() bool {
+	return via.ca2Held || via.ca2Pulse
+}
+
+// CB2Low reports whether CB2 is currently being held or pulsed low.
+func (via *Via6522) CB2Low() bool {
+	return via.cb2Held || via.cb2Pulse
+}
+
+// strobeA pulses CA2 if the CA2 control mode is handshake or pulse output,
+// notifying any HandshakingPeripheral attached to port A.
+func (via *Via6522) strobeA() {
+	switch via.control2Mode(viaPcrOffsetA) {
+	case viaControl2Handshake:
+		via.ca2Held = true
+	case viaControl2Pulse:
+		via.ca2Pulse = true
+	default:
+		return
+	}
+	for _, p := range via.paPeripherals {
+		if hp, ok := p.(HandshakingPeripheral); ok {
+			hp.Strobe()
+		}
+	}
+}
+
+// strobeB pulses CB2 if the CB2 control mode is handshake or pulse output,
+// notifying any HandshakingPeripheral attached to port B.
+func (via *Via6522) strobeB() {
+	switch via.control2Mode(viaPcrOffsetB) {
+	case viaControl2Handshake:
+		via.cb2Held = true
+	case viaControl2Pulse:
+		via.cb2Pulse = true
+	default:
+		return
+	}
+	for _, p := range via.pbPeripherals {
+		if hp, ok := p.(HandshakingPeripheral); ok {
+			hp.Strobe()
+		}
+	}
+}
+
 // Print a byte as ASCII, using escape sequences where necessary.
 func printAsciiByte(b uint8) {
 	r := rune(b)
@@ -183,7 +369,8 @@ func printAsciiByte(b uint8) {
 
 // Read the register specified by the given 4-bit address (0x00..0x0F).
 // TODO: Unlike IRA, reading IRB actully returns bits from ORA for pins
-//       that are programmed as output.
+//
+//	that are programmed as output.
 func (via *Via6522) Read(a uint16) byte {
 	switch a {
 	default:
@@ -199,13 +386,34 @@ func (via *Via6522) Read(a uint16) byte {
 		for _, p := range via.paPeripherals {
 			via.ira |= (p.Read() & p.PinMask())
 		}
+		via.strobeA()
 		return via.readMixedInputOutput(via.ira, via.ora, via.ddra)
 	case 0x2:
 		return via.ddrb
 	case 0x3:
 		return via.ddra
-	case 0xC:
+	case viaT1cl:
+		via.clearIfr(viaIfrT1)
+		return byte(via.t1c)
+	case viaT1ch:
+		return byte(via.t1c >> 8)
+	case viaT1ll:
+		return byte(via.t1l)
+	case viaT1lh:
+		return byte(via.t1l >> 8)
+	case viaT2cl:
+		via.clearIfr(viaIfrT2)
+		return byte(via.t2c)
+	case viaT2ch:
+		return byte(via.t2c >> 8)
+	case viaAcr:
+		return via.acr
+	case viaPcr:
 		return via.pcr
+	case viaIfr:
+		return via.ifr
+	case viaIer:
+		return via.ier | viaIerSetClear
 	}
 }
 
@@ -226,6 +434,17 @@ func (via *Via6522) Reset() {
 	via.ddra = 0
 	via.ddrb = 0
 	via.pcr = 0
+	via.acr = 0
+	via.ifr = 0
+	via.ier = 0
+	via.pb7 = 0
+	via.ca2Held = false
+	via.cb2Held = false
+	via.ca2Pulse = false
+	via.cb2Pulse = false
+	via.ca1Level = 1
+	via.cb1Level = 1
+	via.t1OneShotFired = false
 }
 
 // The address size of the memory-mapped IO.
@@ -246,15 +465,127 @@ func (via *Via6522) Write(a uint16, data byte) {
 	case 0x0:
 		via.orb = data
 		via.handleDataWrite(data&via.ddrb, via.pbPeripherals)
+		via.strobeB()
 	case 0x1:
 		via.ora = data
 		via.handleDataWrite(data&via.ddra, via.paPeripherals)
+		via.strobeA()
 	case 0x2:
 		via.ddrb = data
 	case 0x3:
 		via.ddra = data
-	case 0xC:
+	case viaT1cl:
+		via.t1l = (via.t1l & 0xFF00) | uint16(data)
+	case viaT1ch:
+		via.t1l = (via.t1l & 0x00FF) | uint16(data)<<8
+		via.t1c = via.t1l
+		via.t1OneShotFired = false
+		via.clearIfr(viaIfrT1)
+	case viaT1ll:
+		via.t1l = (via.t1l & 0xFF00) | uint16(data)
+	case viaT1lh:
+		via.t1l = (via.t1l & 0x00FF) | uint16(data)<<8
+		via.clearIfr(viaIfrT1)
+	case viaT2cl:
+		via.t2l = data
+	case viaT2ch:
+		via.t2c = uint16(data)<<8 | uint16(via.t2l)
+		via.clearIfr(viaIfrT2)
+	case viaAcr:
+		via.acr = data
+	case viaPcr:
 		via.pcr = data
+	case viaIfr:
+		via.ifr &^= data // write 1 to clear
+		via.updateIrq()
+	case viaIer:
+		if data&viaIerSetClear != 0 {
+			via.ier |= data &^ viaIerSetClear
+		} else {
+			via.ier &^= data
+		}
+		via.updateIrq()
+	}
+}
+
+// Tick advances T1 and T2 by the given number of CPU cycles, as consumed by
+// the most recently executed instruction. It must be called between
+// instructions for the timers and their interrupts to be cycle-accurate.
+func (via *Via6522) Tick(cycles uint64) {
+	if cycles > 0 {
+		via.ca2Pulse = false
+		via.cb2Pulse = false
+	}
+	for i := uint64(0); i < cycles; i++ {
+		via.tickT1()
+		if via.acr&viaAcrT2PulseCounting == 0 {
+			via.tickT2()
+		}
+	}
+}
+
+// PulsePB6 signals a single negative-edge pulse on PB6, decrementing T2 when
+// it is configured (via ACR bit 5) as a pulse counter rather than a
+// cycle-counting timer.
+func (via *Via6522) PulsePB6() {
+	if via.acr&viaAcrT2PulseCounting != 0 {
+		via.tickT2()
+	}
+}
+
+func (via *Via6522) tickT1() {
+	if via.t1c == 0 {
+		// In one-shot mode, T1 interrupts once per T1C-H write and then
+		// free-runs (counting but not re-interrupting) until reloaded; only
+		// free-run mode (ACR bit 6) re-asserts the IFR bit on every underflow.
+		if via.acr&viaAcrT1FreeRun != 0 || !via.t1OneShotFired {
+			via.setIfr(viaIfrT1)
+			via.t1OneShotFired = true
+		}
+		if via.acr&viaAcrT1Pb7 != 0 {
+			via.pb7 ^= viaAcrT1Pb7
+		}
+		if via.acr&viaAcrT1FreeRun != 0 {
+			via.t1c = via.t1l
+			return
+		}
+	}
+	via.t1c--
+}
+
+func (via *Via6522) tickT2() {
+	if via.t2c == 0 {
+		via.setIfr(viaIfrT2)
+	}
+	via.t2c--
+}
+
+// IRQ reports whether the VIA's aggregate, IER-gated interrupt condition is
+// currently asserted.
+func (via *Via6522) IRQ() bool {
+	return via.ifr&via.ier&0x7F != 0
+}
+
+func (via *Via6522) setIfr(bit byte) {
+	via.ifr |= bit
+	via.updateIrq()
+}
+
+func (via *Via6522) clearIfr(bit byte) {
+	via.ifr &^= bit
+	via.updateIrq()
+}
+
+// updateIrq re-evaluates the aggregate, IER-gated interrupt condition and
+// asserts or releases the attached IrqLine to match it.
+func (via *Via6522) updateIrq() {
+	if via.irq == nil {
+		return
+	}
+	if via.IRQ() {
+		via.irq.Assert()
+	} else {
+		via.irq.Release()
 	}
 }
 
@@ -0,0 +1,315 @@
+package via6522
+
+import "testing"
+
+// testPeripheral is a minimal ParallelPeripheral that connects no pins and
+// ignores all port traffic.
+type testPeripheral struct{}
+
+func (testPeripheral) PinMask() byte { return 0x00 }
+func (testPeripheral) Read() byte    { return 0x00 }
+func (testPeripheral) Shutdown()     {}
+func (testPeripheral) Write(byte)    {}
+func (testPeripheral) String() string {
+	return "testPeripheral"
+}
+
+func newTestVia() *Via6522 {
+	via := NewVia6522(Options{})
+	via.Reset()
+	return via
+}
+
+// fakeIrqLine records Assert/Release calls, standing in for the CPU's shared
+// IRQ line aggregator.
+type fakeIrqLine struct {
+	asserted bool
+}
+
+func (f *fakeIrqLine) Assert()  { f.asserted = true }
+func (f *fakeIrqLine) Release() { f.asserted = false }
+
+func TestAttachIRQAssertsAndReleasesOnUnderflow(t *testing.T) {
+	via := newTestVia()
+	irq := &fakeIrqLine{}
+	via.AttachIRQ(irq)
+	via.Write(viaIer, viaIerSetClear|viaIfrT1)
+	via.Write(viaT1cl, 0x01)
+	via.Write(viaT1ch, 0x00)
+
+	if irq.asserted {
+		t.Fatal("IRQ line asserted before T1 underflow")
+	}
+
+	via.Tick(2) // 1 -> 0 -> underflow
+	if !irq.asserted {
+		t.Fatal("IRQ line not asserted after enabled T1 underflow")
+	}
+
+	via.Read(viaT1cl) // clears the T1 flag
+	if irq.asserted {
+		t.Fatal("IRQ line not released after clearing T1 flag")
+	}
+}
+
+func TestIrqAggregatorHoldsLineUntilAllSourcesRelease(t *testing.T) {
+	target := &fakeIrqLine{}
+	agg := NewIrqAggregator(target)
+	viaSource := agg.NewSource()
+	aciaSource := agg.NewSource()
+
+	viaSource.Assert()
+	if !target.asserted {
+		t.Fatal("target not asserted after first source asserted")
+	}
+
+	aciaSource.Assert()
+	viaSource.Release()
+	if !target.asserted {
+		t.Fatal("target released while a second source was still asserting")
+	}
+
+	aciaSource.Release()
+	if target.asserted {
+		t.Fatal("target still asserted after every source released")
+	}
+}
+
+func TestT1OneShotUnderflowSetsIfrAndIrq(t *testing.T) {
+	via := newTestVia()
+	via.Write(viaIer, viaIerSetClear|viaIfrT1)
+	via.Write(viaT1cl, 0x02)
+	via.Write(viaT1ch, 0x00) // loads counter to 2, clears IFR
+
+	if via.IRQ() {
+		t.Fatal("IRQ asserted before T1 underflow")
+	}
+
+	via.Tick(2) // counter: 2 -> 1 -> 0, no underflow yet
+	if via.Read(viaIfr)&viaIfrT1 != 0 {
+		t.Fatal("T1 flag set before underflow")
+	}
+
+	via.Tick(1) // counter is 0: underflow fires this cycle
+	if via.Read(viaIfr)&viaIfrT1 == 0 {
+		t.Fatal("T1 flag not set on underflow")
+	}
+	if !via.IRQ() {
+		t.Fatal("IRQ not asserted after T1 underflow with IER enabled")
+	}
+
+	via.Read(viaT1cl) // reading T1C-L clears the flag
+	if via.Read(viaIfr)&viaIfrT1 != 0 {
+		t.Fatal("T1 flag not cleared by reading T1C-L")
+	}
+	if via.IRQ() {
+		t.Fatal("IRQ still asserted after clearing T1 flag")
+	}
+}
+
+func TestT1OneShotDoesNotRefireOnSecondWrap(t *testing.T) {
+	via := newTestVia()
+	via.Write(viaIer, viaIerSetClear|viaIfrT1)
+	via.Write(viaT1cl, 0x02)
+	via.Write(viaT1ch, 0x00) // loads counter to 2, clears IFR, starts one-shot
+
+	via.Tick(3) // 2 -> 1 -> 0 (underflow, fires) -> 0xFFFF
+	if via.Read(viaIfr)&viaIfrT1 == 0 {
+		t.Fatal("T1 flag not set on first underflow")
+	}
+	via.Read(viaT1cl) // clears the flag, but does NOT reload or restart one-shot
+
+	via.Tick(0x10000) // counter free-runs all the way through another 16-bit wrap
+	if via.Read(viaIfr)&viaIfrT1 != 0 {
+		t.Fatal("one-shot T1 re-fired on a second wrap without a T1C-H reload")
+	}
+}
+
+func TestT1FreeRunReloadsFromLatch(t *testing.T) {
+	via := newTestVia()
+	via.Write(viaAcr, viaAcrT1FreeRun)
+	via.Write(viaT1cl, 0x03)
+	via.Write(viaT1ch, 0x00) // latch and counter = 3
+
+	via.Tick(4) // 3 -> 2 -> 1 -> 0 (underflow, reload from latch to 3)
+	if via.Read(viaIfr)&viaIfrT1 == 0 {
+		t.Fatal("T1 flag not set after free-running underflow")
+	}
+	// Check the counter via the struct field: reading T1C-L itself clears the T1 flag.
+	if via.t1c != 3 {
+		t.Fatalf("T1 counter = %d, want 3 after reload", via.t1c)
+	}
+}
+
+func TestT1Pb7TogglesOnUnderflowWhenAcrBit7Set(t *testing.T) {
+	via := newTestVia()
+	via.Write(viaAcr, viaAcrT1FreeRun|viaAcrT1Pb7)
+	via.Write(viaT1cl, 0x01)
+	via.Write(viaT1ch, 0x00)
+
+	before := via.pb7
+	via.Tick(2) // underflow once
+	if via.pb7 == before {
+		t.Fatal("PB7 did not toggle on T1 underflow")
+	}
+}
+
+func TestT2OneShotDoesNotReload(t *testing.T) {
+	via := newTestVia()
+	via.Write(viaT2cl, 0x01)
+	via.Write(viaT2ch, 0x00) // counter = 1
+
+	via.Tick(2) // 1 -> 0 (underflow) -> 0xFFFF
+	if via.Read(viaIfr)&viaIfrT2 == 0 {
+		t.Fatal("T2 flag not set on underflow")
+	}
+	if got := uint16(via.Read(viaT2cl)) | uint16(via.Read(viaT2ch))<<8; got != 0xFFFF {
+		t.Fatalf("T2 counter = 0x%04X, want 0xFFFF (wrapped, not reloaded)", got)
+	}
+}
+
+func TestT2PulseCountingIgnoresTick(t *testing.T) {
+	via := newTestVia()
+	via.Write(viaAcr, viaAcrT2PulseCounting)
+	via.Write(viaT2cl, 0x01)
+	via.Write(viaT2ch, 0x00) // counter = 1
+
+	via.Tick(10) // Tick must not advance T2 in pulse-counting mode
+	if via.Read(viaIfr)&viaIfrT2 != 0 {
+		t.Fatal("T2 underflowed via Tick() while in pulse-counting mode")
+	}
+
+	via.PulsePB6() // 1 -> 0
+	via.PulsePB6() // 0 -> underflow -> 0xFFFF
+	if via.Read(viaIfr)&viaIfrT2 == 0 {
+		t.Fatal("T2 flag not set after two PB6 pulses")
+	}
+}
+
+// loopbackPeripheral is a HandshakingPeripheral that drives the
+// CA2/CB2-CA1/CB1 handshake end-to-end: it records whatever is written to it
+// and immediately acknowledges the strobe, as a real IEEE-1284-style
+// peripheral would once it had latched the byte.
+type loopbackPeripheral struct {
+	ack      func()
+	received byte
+	data     byte
+}
+
+func (p *loopbackPeripheral) PinMask() byte  { return 0xFF }
+func (p *loopbackPeripheral) Read() byte     { return p.data }
+func (p *loopbackPeripheral) Shutdown()      {}
+func (p *loopbackPeripheral) Write(b byte)   { p.received = b }
+func (p *loopbackPeripheral) String() string { return "loopbackPeripheral" }
+func (p *loopbackPeripheral) Strobe()        { p.ack() }
+
+func TestHandshakeOutputPulsesCA2UntilAckA(t *testing.T) {
+	via := newTestVia()
+	p := &loopbackPeripheral{}
+	p.ack = func() { via.AckA() }
+	via.AttachToPortA(p)
+	via.Write(viaDdra, 0xFF) // configure port A pins as outputs
+	via.Write(viaPcr, viaControl2Handshake<<(viaPcrOffsetA+1))
+
+	via.Write(viaOra, 0x42)
+	if p.received != 0x42 {
+		t.Fatalf("peripheral received 0x%02X, want 0x42", p.received)
+	}
+	if via.CA2Low() {
+		t.Fatal("CA2 still low after peripheral acknowledged via Strobe()")
+	}
+	if via.Read(viaIfr)&viaIfrCa1 == 0 {
+		t.Fatal("CA1 flag not set after AckA()")
+	}
+}
+
+func TestAckAIgnoresNonActiveEdge(t *testing.T) {
+	via := newTestVia()
+	p := &loopbackPeripheral{}
+	p.ack = func() {} // AckA is called explicitly below, not via Strobe()
+	via.AttachToPortA(p)
+	via.Write(viaDdra, 0xFF)
+	// PCR bit 0 = 1: CA1 is configured for the positive (low-to-high) edge,
+	// so the first AckA() call (high -> low) must not set the flag or
+	// release CA2; it's the second call (low -> high) that should.
+	via.Write(viaPcr, viaControl2Handshake<<(viaPcrOffsetA+1)|1<<viaPcrOffsetA)
+
+	via.Write(viaOra, 0x42)
+	via.AckA()
+	if via.Read(viaIfr)&viaIfrCa1 != 0 {
+		t.Fatal("CA1 flag set on the non-active edge")
+	}
+	if !via.CA2Low() {
+		t.Fatal("CA2 released on the non-active edge")
+	}
+
+	via.AckA()
+	if via.Read(viaIfr)&viaIfrCa1 == 0 {
+		t.Fatal("CA1 flag not set on the configured active edge")
+	}
+	if via.CA2Low() {
+		t.Fatal("CA2 still low after the configured active edge")
+	}
+}
+
+func TestHandshakeOutputHoldsCA2WithoutAck(t *testing.T) {
+	via := newTestVia()
+	p := &loopbackPeripheral{}
+	p.ack = func() {} // never acknowledges
+	via.AttachToPortA(p)
+	via.Write(viaPcr, viaControl2Handshake<<(viaPcrOffsetA+1))
+
+	via.Write(viaOra, 0x01)
+	if !via.CA2Low() {
+		t.Fatal("CA2 should stay low until AckA() is called")
+	}
+}
+
+func TestPulseOutputReleasesCA2OnNextTick(t *testing.T) {
+	via := newTestVia()
+	p := &loopbackPeripheral{}
+	p.ack = func() {}
+	via.AttachToPortA(p)
+	via.Write(viaPcr, viaControl2Pulse<<(viaPcrOffsetA+1))
+
+	via.Write(viaOra, 0x01)
+	if !via.CA2Low() {
+		t.Fatal("CA2 not pulsed low immediately after ORA write in pulse mode")
+	}
+
+	via.Tick(1)
+	if via.CA2Low() {
+		t.Fatal("CA2 still low after the next Tick() in pulse mode")
+	}
+}
+
+func TestReadIraStrobesCA2InHandshakeMode(t *testing.T) {
+	via := newTestVia()
+	p := &loopbackPeripheral{}
+	p.ack = func() { via.AckA() }
+	via.AttachToPortA(p)
+	via.Write(viaPcr, viaControl2Handshake<<(viaPcrOffsetA+1))
+	via.Read(viaIfr) // IFR reads have no side effects; setup strobed nothing
+
+	p.ack = func() {} // stop auto-acking so we can observe the held line
+	via.Read(viaOra)
+	if !via.CA2Low() {
+		t.Fatal("CA2 not pulsed low by reading IRA in handshake mode")
+	}
+}
+
+func TestIerWriteSetsAndClearsBits(t *testing.T) {
+	via := newTestVia()
+	via.Write(viaIer, viaIerSetClear|viaIfrT1|viaIfrT2)
+	if via.Read(viaIer)&(viaIfrT1|viaIfrT2) != viaIfrT1|viaIfrT2 {
+		t.Fatal("IER did not enable T1 and T2 bits")
+	}
+
+	via.Write(viaIer, viaIfrT1) // bit 7 clear: disable T1 only
+	if via.Read(viaIer)&viaIfrT1 != 0 {
+		t.Fatal("IER still has T1 enabled after clearing write")
+	}
+	if via.Read(viaIer)&viaIfrT2 == 0 {
+		t.Fatal("IER lost T2 enable bit after unrelated clearing write")
+	}
+}